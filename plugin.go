@@ -3,10 +3,21 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net"
+	"net/http"
 	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
 // PingPlugin is the main plugin struct
@@ -46,79 +57,827 @@ func (p *PingPlugin) executeWithIteration(params map[string]interface{}) (interf
 
 	// Update state
 	p.IterationCount++
-	if resultMap, ok := result.(map[string]interface{}); ok {
-		// Create a copy of the result for history to avoid reference issues
-		historyCopy := make(map[string]interface{})
-		for k, v := range resultMap {
-			historyCopy[k] = v
-		}
-		p.Results = append(p.Results, historyCopy)
-
-		// Add iteration metadata to the result
-		resultMap["iterationCount"] = p.IterationCount
-		resultMap["elapsedTime"] = time.Since(p.StartTime).String()
-
-		// Create a summary for the UI
-		host := resultMap["host"].(string)
-		packetLoss := resultMap["packetLoss"].(float64)
-		timeAvg := resultMap["timeAvg"].(float64)
-
-		// Add iteration_data for UI display
-		resultMap["iteration_data"] = map[string]interface{}{
-			"can_iterate":        true,
-			"supports_iteration": true,
-			"iteration_summary": fmt.Sprintf(
-				"Iteration %d: %s - %.1f%% loss, avg %.1f ms",
-				p.IterationCount,
-				host,
-				packetLoss,
-				timeAvg,
-			),
-		}
-
-		// Add history summary
-		if len(p.Results) > 1 {
-			history := make([]map[string]interface{}, 0)
-			for i, res := range p.Results {
-				if resMap, ok := res.(map[string]interface{}); ok {
-					// Create a simplified history entry
-					host := resMap["host"].(string)
-					timestamp := resMap["timestamp"].(string)
-					packetLoss := resMap["packetLoss"].(float64)
-					timeAvg := resMap["timeAvg"].(float64)
-					
-					historyEntry := map[string]interface{}{
-						"iteration":  i+1,
-						"timestamp":  timestamp,
-						"host":       host,
-						"packetLoss": packetLoss,
-						"timeAvg":    timeAvg,
-					}
-					history = append(history, historyEntry)
+	resultMap, ok := result.(map[string]interface{})
+	if !ok {
+		return result, nil
+	}
+
+	// Create a copy of the result for history to avoid reference issues
+	historyCopy := make(map[string]interface{})
+	for k, v := range resultMap {
+		historyCopy[k] = v
+	}
+	p.Results = append(p.Results, historyCopy)
+
+	// Add iteration metadata to the result
+	resultMap["iterationCount"] = p.IterationCount
+	resultMap["elapsedTime"] = time.Since(p.StartTime).String()
+
+	// Create a summary for the UI
+	aggregate, _ := resultMap["aggregate"].(map[string]interface{})
+	hostCount, _ := aggregate["hostCount"].(int)
+	packetLoss, _ := aggregate["packetLoss"].(float64)
+	timeAvg, _ := aggregate["timeAvg"].(float64)
+
+	resultMap["iteration_data"] = map[string]interface{}{
+		"can_iterate":        true,
+		"supports_iteration": true,
+		"iteration_summary": fmt.Sprintf(
+			"Iteration %d: %d host(s) - %.1f%% loss, avg %.1f ms",
+			p.IterationCount,
+			hostCount,
+			packetLoss,
+			timeAvg,
+		),
+	}
+
+	// Add a per-host time series so the UI can render one sparkline per host
+	if len(p.Results) > 1 {
+		history := make(map[string][]map[string]interface{})
+		for i, res := range p.Results {
+			resMap, ok := res.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			timestamp, _ := resMap["timestamp"].(string)
+			hosts, ok := resMap["hosts"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for host, hr := range hosts {
+				hrMap, ok := hr.(map[string]interface{})
+				if !ok {
+					continue
 				}
+				history[host] = append(history[host], map[string]interface{}{
+					"iteration":  i + 1,
+					"timestamp":  timestamp,
+					"packetLoss": hrMap["packetLoss"],
+					"timeAvg":    hrMap["timeAvg"],
+				})
 			}
-			resultMap["history"] = history
 		}
+		resultMap["history"] = history
 	}
 
 	return result, nil
 }
 
-// performPing handles the actual ping execution logic
-func (p *PingPlugin) performPing(params map[string]interface{}) (interface{}, error) {
-	host, _ := params["host"].(string)
-	countParam, ok := params["count"].(float64)
+// paramString reads a string param, falling back to def if absent or the wrong type
+func paramString(params map[string]interface{}, key, def string) string {
+	if v, ok := params[key].(string); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// paramFloat reads a numeric param (JSON numbers decode as float64), falling back to def
+func paramFloat(params map[string]interface{}, key string, def float64) float64 {
+	if v, ok := params[key].(float64); ok {
+		return v
+	}
+	return def
+}
+
+// paramInt reads a numeric param as an int, falling back to def
+func paramInt(params map[string]interface{}, key string, def int) int {
+	return int(paramFloat(params, key, float64(def)))
+}
+
+// paramStringSlice reads a JSON array-of-strings param, returning nil if
+// absent or malformed.
+func paramStringSlice(params map[string]interface{}, key string) []string {
+	raw, ok := params[key].([]interface{})
 	if !ok {
-		countParam = 4 // Default count
+		return nil
 	}
-	count := int(countParam)
+	out := make([]string, 0, len(raw))
+	for _, item := range raw {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
 
-	if host == "" {
+// parseHosts reads the "host" param, which may be either a single host
+// string or a JSON array of host strings, and returns the list of hosts to
+// ping.
+func parseHosts(params map[string]interface{}) ([]string, error) {
+	switch v := params["host"].(type) {
+	case string:
+		if v == "" {
+			return nil, fmt.Errorf("host parameter is required")
+		}
+		return []string{v}, nil
+	case []interface{}:
+		hosts := make([]string, 0, len(v))
+		for _, item := range v {
+			host, ok := item.(string)
+			if !ok || host == "" {
+				return nil, fmt.Errorf("host array entries must be non-empty strings")
+			}
+			hosts = append(hosts, host)
+		}
+		if len(hosts) == 0 {
+			return nil, fmt.Errorf("host parameter is required")
+		}
+		return hosts, nil
+	default:
 		return nil, fmt.Errorf("host parameter is required")
 	}
+}
+
+// performPing fans out performPing's per-host work across a worker pool so
+// a single invocation can cover several hosts at once, then aggregates the
+// per-host results.
+func (p *PingPlugin) performPing(params map[string]interface{}) (interface{}, error) {
+	hosts, err := parseHosts(params)
+	if err != nil {
+		return nil, err
+	}
+
+	count := paramInt(params, "count", 4)
+	parallelism := paramInt(params, "parallelism", len(hosts))
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	if parallelism > len(hosts) {
+		parallelism = len(hosts)
+	}
+
+	jobs := make(chan string, len(hosts))
+	type hostResult struct {
+		host   string
+		result map[string]interface{}
+	}
+	results := make(chan hostResult, len(hosts))
+
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range jobs {
+				results <- hostResult{host: host, result: p.pingHost(host, count, params)}
+			}
+		}()
+	}
+	for _, host := range hosts {
+		jobs <- host
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+
+	hostResults := make(map[string]interface{}, len(hosts))
+	for r := range results {
+		hostResults[r.host] = r.result
+	}
+
+	return map[string]interface{}{
+		"hosts":     hostResults,
+		"aggregate": aggregateResults(hostResults),
+		"timestamp": time.Now().Format(time.RFC3339),
+	}, nil
+}
+
+// pingHost runs the native/system/simulated fallback chain for a single
+// host and always returns a result map, labelling it clearly when it had to
+// fall back to simulation.
+func (p *PingPlugin) pingHost(host string, count int, params map[string]interface{}) map[string]interface{} {
+	method := paramString(params, "method", "native")
+
+	var result map[string]interface{}
+	var err error
+
+	switch method {
+	case "system":
+		result, err = p.systemPing(host, count, params)
+	default:
+		result, err = p.nativePing(host, count, params)
+		if err != nil {
+			// Native sockets can fail for permission reasons (no CAP_NET_RAW and
+			// net.ipv4.ping_group_range not configured); fall back to the system
+			// ping binary before giving up.
+			result, err = p.systemPing(host, count, params)
+		}
+	}
+
+	if err != nil {
+		// Both the native and system pingers failed (e.g. sandboxed environment
+		// with neither raw sockets nor a ping binary available). Fall back to a
+		// clearly-labelled simulation so the plugin still returns something.
+		fallback := p.simulatedPing(host, count)
+		fallback["note"] = fmt.Sprintf("This is a simulated result because real ping failed: %s", err)
+		return fallback
+	}
+
+	return result
+}
+
+// aggregateResults combines the per-host result maps into a single summary:
+// totals for transmitted/received packets, overall packet loss, and the
+// min/avg/max RTT across all hosts that returned at least one reply.
+func aggregateResults(hostResults map[string]interface{}) map[string]interface{} {
+	var transmitted, received, hostsUp int
+	var avgs []float64
+	timeMin, timeMax := math.Inf(1), math.Inf(-1)
+
+	for _, v := range hostResults {
+		result, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if t, ok := result["transmitted"].(int); ok {
+			transmitted += t
+		}
+		if r, ok := result["received"].(int); ok {
+			received += r
+			if r > 0 {
+				hostsUp++
+			}
+		}
+		if avg, ok := result["timeAvg"].(float64); ok && avg > 0 {
+			avgs = append(avgs, avg)
+		}
+		if min, ok := result["timeMin"].(float64); ok && min > 0 && min < timeMin {
+			timeMin = min
+		}
+		if max, ok := result["timeMax"].(float64); ok && max > timeMax {
+			timeMax = max
+		}
+	}
+
+	packetLoss := 100.0
+	if transmitted > 0 {
+		packetLoss = 100.0 * float64(transmitted-received) / float64(transmitted)
+	}
+
+	var timeAvg float64
+	if len(avgs) > 0 {
+		sum := 0.0
+		for _, avg := range avgs {
+			sum += avg
+		}
+		timeAvg = sum / float64(len(avgs))
+	}
+	if math.IsInf(timeMin, 1) {
+		timeMin = 0
+	}
+	if math.IsInf(timeMax, -1) {
+		timeMax = 0
+	}
+
+	return map[string]interface{}{
+		"hostCount":   len(hostResults),
+		"hostsUp":     hostsUp,
+		"transmitted": transmitted,
+		"received":    received,
+		"packetLoss":  packetLoss,
+		"timeMin":     timeMin,
+		"timeAvg":     timeAvg,
+		"timeMax":     timeMax,
+	}
+}
+
+// nativePing sends ICMP echo requests directly over a raw or unprivileged
+// datagram socket, following the approach telegraf adopted when it moved
+// from shelling out to `ping` to using golang.org/x/net/icmp directly.
+func (p *PingPlugin) nativePing(host string, count int, params map[string]interface{}) (map[string]interface{}, error) {
+	dstIP, family, err := resolveHost(host, paramString(params, "ipVersion", "auto"))
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(paramFloat(params, "interval", 1.0) * float64(time.Second))
+	timeout := time.Duration(paramFloat(params, "timeout", 2.0) * float64(time.Second))
+	ttl := paramInt(params, "ttl", 64)
+	size := paramInt(params, "packetSize", 56)
+	id := paramInt(params, "identifier", os.Getpid()&0xffff)
+
+	echoRequest, echoReply, rawNetwork, udpNetwork, icmpProto := icmpFamilyParams(family)
+
+	network, address := rawNetwork, "0.0.0.0"
+	if family == "6" {
+		address = "::"
+	}
+	privileged := true
+	conn, err := icmp.ListenPacket(network, address)
+	if err != nil {
+		// No CAP_NET_RAW: fall back to an unprivileged datagram socket, which
+		// Linux supports for ICMP echo when net.ipv4.ping_group_range (or its
+		// IPv6 equivalent) permits it.
+		network = udpNetwork
+		privileged = false
+		conn, err = icmp.ListenPacket(network, address)
+		if err != nil {
+			return nil, fmt.Errorf("opening icmp socket: %v", err)
+		}
+	}
+	defer conn.Close()
+
+	if privileged {
+		if family == "6" {
+			if pc := conn.IPv6PacketConn(); pc != nil {
+				_ = pc.SetHopLimit(ttl)
+			}
+		} else if pc := conn.IPv4PacketConn(); pc != nil {
+			_ = pc.SetTTL(ttl)
+		}
+	}
+
+	// Ask the kernel to hand back the TTL/hop limit actually seen on each
+	// reply, so packets[].ttl reflects the real path instead of the value we
+	// requested for our own outgoing packets.
+	if family == "6" {
+		if pc := conn.IPv6PacketConn(); pc != nil {
+			_ = pc.SetControlMessage(ipv6.FlagHopLimit, true)
+		}
+	} else if pc := conn.IPv4PacketConn(); pc != nil {
+		_ = pc.SetControlMessage(ipv4.FlagTTL, true)
+	}
+
+	payload := make([]byte, size)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	var rawOutput strings.Builder
+	fmt.Fprintf(&rawOutput, "PING %s (%s) %d bytes of data.\n", host, dstIP.String(), size)
+
+	rtts := make([]float64, 0, count)
+	packets := make([]map[string]interface{}, 0, count)
+	transmitted := 0
+	received := 0
+
+	for seq := 1; seq <= count; seq++ {
+		wm := icmp.Message{
+			Type: echoRequest,
+			Code: 0,
+			Body: &icmp.Echo{
+				ID:   id,
+				Seq:  seq,
+				Data: payload,
+			},
+		}
+		wb, err := wm.Marshal(nil)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling icmp message: %v", err)
+		}
+
+		var dst net.Addr = &net.IPAddr{IP: dstIP}
+		if !privileged {
+			dst = &net.UDPAddr{IP: dstIP}
+		}
+
+		transmitted++
+		start := time.Now()
+		if _, err := conn.WriteTo(wb, dst); err != nil {
+			fmt.Fprintf(&rawOutput, "write error for icmp_seq=%d: %v\n", seq, err)
+			packets = append(packets, map[string]interface{}{
+				"seq": seq, "rtt_ms": 0.0, "ttl": 0, "received": false,
+			})
+			if seq < count {
+				time.Sleep(interval)
+			}
+			continue
+		}
+
+		if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, fmt.Errorf("setting read deadline: %v", err)
+		}
+
+		rb := make([]byte, 1500)
+		matched := false
+		for {
+			n, peer, replyTTL, err := readICMPReply(conn, family, rb, ttl)
+			if err != nil {
+				// Timeout or other read error: this probe is lost.
+				break
+			}
+			// Raw ICMP sockets see every reply on the host, not just ones
+			// addressed to this probe, so concurrent pings of other hosts
+			// (which share this process's default identifier) could
+			// otherwise be mistaken for this one's reply.
+			if !peerAddrIP(peer).Equal(dstIP) {
+				continue
+			}
+			rm, err := icmp.ParseMessage(icmpProto, rb[:n])
+			if err != nil {
+				continue
+			}
+			if rm.Type != echoReply {
+				continue
+			}
+			echo, ok := rm.Body.(*icmp.Echo)
+			if !ok || echo.ID != id || echo.Seq != seq {
+				continue
+			}
+			rtt := time.Since(start)
+			rttMs := float64(rtt.Microseconds()) / 1000.0
+			rtts = append(rtts, rttMs)
+			received++
+			matched = true
+			fmt.Fprintf(&rawOutput, "%d bytes from %s: icmp_seq=%d ttl=%d time=%.3f ms\n",
+				n, dstIP.String(), seq, replyTTL, rttMs)
+			packets = append(packets, map[string]interface{}{
+				"seq": seq, "rtt_ms": rttMs, "ttl": replyTTL, "received": true,
+			})
+			break
+		}
+		if !matched {
+			packets = append(packets, map[string]interface{}{
+				"seq": seq, "rtt_ms": 0.0, "ttl": 0, "received": false,
+			})
+		}
+
+		if seq < count {
+			time.Sleep(interval)
+		}
+	}
+
+	packetLoss := 100.0
+	if transmitted > 0 {
+		packetLoss = 100.0 * float64(transmitted-received) / float64(transmitted)
+	}
 
-	// Only use the simulated ping function to avoid permission issues
-	return p.simulatedPing(host, count), nil
+	timeMin, timeAvg, timeMax, timeStdDev := rttStats(rtts)
+	percentiles := rttPercentiles(rtts)
+
+	fmt.Fprintf(&rawOutput, "\n--- %s ping statistics ---\n", host)
+	fmt.Fprintf(&rawOutput, "%d packets transmitted, %d received, %.1f%% packet loss\n",
+		transmitted, received, packetLoss)
+	fmt.Fprintf(&rawOutput, "rtt min/avg/max/mdev = %.3f/%.3f/%.3f/%.3f ms\n",
+		timeMin, timeAvg, timeMax, timeStdDev)
+
+	return map[string]interface{}{
+		"host":        host,
+		"packets":     packets,
+		"p50":         percentiles["p50"],
+		"p90":         percentiles["p90"],
+		"p95":         percentiles["p95"],
+		"p99":         percentiles["p99"],
+		"jitter":      rttJitter(rtts),
+		"resolvedIP":  dstIP.String(),
+		"ipVersion":   family,
+		"transmitted": transmitted,
+		"received":    received,
+		"packetLoss":  packetLoss,
+		"timeMin":     timeMin,
+		"timeAvg":     timeAvg,
+		"timeMax":     timeMax,
+		"timeStdDev":  timeStdDev,
+		"timestamp":   time.Now().Format(time.RFC3339),
+		"rawOutput":   rawOutput.String(),
+		"method":      "native",
+	}, nil
+}
+
+// resolveHost looks up host and returns an address matching the requested
+// IP version ("4", "6", or "auto" for whichever the resolver returns first),
+// along with the family of the address actually chosen.
+func resolveHost(host, ipVersion string) (net.IP, string, error) {
+	ips, err := net.LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return nil, "", fmt.Errorf("could not resolve host %s: %v", host, err)
+	}
+
+	wantV6 := ipVersion == "6"
+	wantV4 := ipVersion == "4"
+
+	for _, ip := range ips {
+		isV4 := ip.To4() != nil
+		switch {
+		case wantV4 && isV4:
+			return ip, "4", nil
+		case wantV6 && !isV4:
+			return ip, "6", nil
+		case !wantV4 && !wantV6:
+			if isV4 {
+				return ip, "4", nil
+			}
+			return ip, "6", nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("host %s has no address for ip version %q", host, ipVersion)
+}
+
+// icmpFamilyParams returns the ICMP echo request/reply types, the raw and
+// unprivileged datagram network names, and the protocol number ParseMessage
+// expects, for the given IP family ("4" or "6").
+func icmpFamilyParams(family string) (echoRequest, echoReply icmp.Type, rawNetwork, udpNetwork string, proto int) {
+	if family == "6" {
+		return ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply, "ip6:ipv6-icmp", "udp6", 58
+	}
+	return ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply, "ip4:icmp", "udp4", 1
+}
+
+// peerAddrIP extracts the IP out of the net.Addr ReadFrom returns, which is
+// a *net.IPAddr on a raw socket and a *net.UDPAddr on an unprivileged one.
+func peerAddrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	default:
+		return nil
+	}
+}
+
+// readICMPReply reads one packet off conn and returns the TTL/hop limit the
+// kernel reported for it via the control message enabled above. Falls back
+// to requestedTTL if the platform or socket type didn't supply one, so a
+// probe that can't read the real value still reports something plausible
+// rather than 0.
+func readICMPReply(conn *icmp.PacketConn, family string, rb []byte, requestedTTL int) (n int, peer net.Addr, ttl int, err error) {
+	if family == "6" {
+		if pc := conn.IPv6PacketConn(); pc != nil {
+			var cm *ipv6.ControlMessage
+			n, cm, peer, err = pc.ReadFrom(rb)
+			ttl = requestedTTL
+			if cm != nil {
+				ttl = cm.HopLimit
+			}
+			return n, peer, ttl, err
+		}
+	} else if pc := conn.IPv4PacketConn(); pc != nil {
+		var cm *ipv4.ControlMessage
+		n, cm, peer, err = pc.ReadFrom(rb)
+		ttl = requestedTTL
+		if cm != nil {
+			ttl = cm.TTL
+		}
+		return n, peer, ttl, err
+	}
+
+	n, peer, err = conn.ReadFrom(rb)
+	return n, peer, requestedTTL, err
+}
+
+// systemPing falls back to invoking the OS `ping` binary and parsing its
+// output, for platforms or environments where the native socket path above
+// isn't usable.
+func (p *PingPlugin) systemPing(host string, count int, params map[string]interface{}) (map[string]interface{}, error) {
+	binary := paramString(params, "binary", "ping")
+	rawArgs := paramStringSlice(params, "arguments")
+	ipVersion := paramString(params, "ipVersion", "auto")
+	intendedIPv6 := ipVersion == "6"
+
+	var args []string
+	if len(rawArgs) > 0 {
+		// An explicit argument list takes over completely, so operators can
+		// point this at fping, hping3, or a setuid wrapper without the plugin
+		// trying to layer its own flags on top.
+		args = rawArgs
+	} else {
+		// buildPingArgs adds -4/-6 itself, so this works whether or not the
+		// caller also overrides "binary".
+		args = buildPingArgs(host, count, params)
+	}
+
+	cmd := exec.Command(binary, args...)
+	output, err := cmd.CombinedOutput()
+	rawOutput := string(output)
+	if err != nil && binary == "ping" && intendedIPv6 && len(rawArgs) == 0 {
+		// Some distros only ship the legacy ping6 wrapper and reject -6 on
+		// the dual-stack `ping`; fall back to it before giving up. ping6
+		// implies v6 on its own and doesn't accept -6, so strip it.
+		ping6Args := make([]string, 0, len(args))
+		for _, a := range args {
+			if a != "-6" {
+				ping6Args = append(ping6Args, a)
+			}
+		}
+		cmd = exec.Command("ping6", ping6Args...)
+		output, err = cmd.CombinedOutput()
+		rawOutput = string(output)
+	}
+	if err != nil {
+		if len(rawOutput) == 0 {
+			return nil, fmt.Errorf("running system ping: %v", err)
+		}
+		// ping exits non-zero on packet loss; the output is still useful.
+	}
+
+	stats, statsErr := parsePingOutput(rawOutput)
+	if statsErr != nil {
+		return nil, fmt.Errorf("parsing system ping output: %v", statsErr)
+	}
+
+	stats["host"] = host
+	stats["ipVersion"] = resolvedFamilyFromPingOutput(rawOutput, intendedIPv6)
+	stats["timestamp"] = time.Now().Format(time.RFC3339)
+	stats["rawOutput"] = rawOutput
+	stats["method"] = "system"
+	stats["binary"] = binary
+	return stats, nil
+}
+
+// buildPingArgs assembles the argument list for the system `ping` binary
+// from the plugin's generic params, matching the flags used by iputils ping.
+func buildPingArgs(host string, count int, params map[string]interface{}) []string {
+	args := []string{"-c", strconv.Itoa(count)}
+
+	// iputils ping also accepts -4/-6 directly, so request the family this
+	// way too: ping6 selection alone gets skipped whenever the caller also
+	// overrides "binary", and plain `ping` otherwise falls back to whatever
+	// family the system resolver prefers.
+	switch paramString(params, "ipVersion", "auto") {
+	case "4":
+		args = append(args, "-4")
+	case "6":
+		args = append(args, "-6")
+	}
+
+	if interval := paramFloat(params, "interval", 0); interval > 0 {
+		args = append(args, "-i", strconv.FormatFloat(interval, 'f', -1, 64))
+	}
+	if timeout := paramFloat(params, "timeout", 0); timeout > 0 {
+		args = append(args, "-W", strconv.Itoa(int(math.Ceil(timeout))))
+	}
+	if ttl := paramInt(params, "ttl", 0); ttl > 0 {
+		args = append(args, "-t", strconv.Itoa(ttl))
+	}
+	if size := paramInt(params, "packetSize", 0); size > 0 {
+		args = append(args, "-s", strconv.Itoa(size))
+	}
+
+	return append(args, host)
+}
+
+var (
+	// pingStatsRe deliberately doesn't anchor on English words like
+	// "transmitted"/"received"/"packet loss": non-English ping builds (and
+	// tools like fping/hping3) translate or omit them, but preserve the
+	// transmitted/received/loss-percent number order, so we just skip
+	// whatever non-numeric text separates the three values.
+	pingStatsRe  = regexp.MustCompile(`(\d+)[^%\d\n]*?(\d+)[^%\d\n]*?([\d.]+)\s*%`)
+	pingRttRe    = regexp.MustCompile(`=\s*([\d.]+)/([\d.]+)/([\d.]+)/([\d.]+)`)
+	pingHeaderRe = regexp.MustCompile(`PING\s+\S+\s+\(([^)]+)\)`)
+)
+
+// resolvedFamilyFromPingOutput reports which IP family the system ping
+// binary actually probed, by parsing the resolved address out of its
+// "PING host (ip)" banner line (present across locales since the address
+// itself isn't translated). Falls back to intendedIPv6 if the banner can't
+// be found or parsed, e.g. because a custom binary/arguments were used.
+func resolvedFamilyFromPingOutput(output string, intendedIPv6 bool) string {
+	if m := pingHeaderRe.FindStringSubmatch(output); m != nil {
+		if ip := net.ParseIP(m[1]); ip != nil {
+			if ip.To4() != nil {
+				return "4"
+			}
+			return "6"
+		}
+	}
+	if intendedIPv6 {
+		return "6"
+	}
+	return "4"
+}
+
+// parsePingOutput extracts the summary stats out of the textual output of
+// the system `ping` binary. The regexes above are numeric-only so this also
+// works for non-English locales and ping-like tools such as fping/hping3.
+func parsePingOutput(output string) (map[string]interface{}, error) {
+	statsMatch := pingStatsRe.FindStringSubmatch(output)
+	if statsMatch == nil {
+		return nil, fmt.Errorf("could not find packet statistics in ping output")
+	}
+
+	transmitted, _ := strconv.Atoi(statsMatch[1])
+	received, _ := strconv.Atoi(statsMatch[2])
+	packetLoss, _ := strconv.ParseFloat(statsMatch[3], 64)
+
+	var timeMin, timeAvg, timeMax, timeStdDev float64
+	if rttMatch := pingRttRe.FindStringSubmatch(output); rttMatch != nil {
+		timeMin, _ = strconv.ParseFloat(rttMatch[1], 64)
+		timeAvg, _ = strconv.ParseFloat(rttMatch[2], 64)
+		timeMax, _ = strconv.ParseFloat(rttMatch[3], 64)
+		timeStdDev, _ = strconv.ParseFloat(rttMatch[4], 64)
+	}
+
+	packets, rtts := parsePingPackets(output)
+	percentiles := rttPercentiles(rtts)
+
+	return map[string]interface{}{
+		"transmitted": transmitted,
+		"received":    received,
+		"packetLoss":  packetLoss,
+		"timeMin":     timeMin,
+		"timeAvg":     timeAvg,
+		"timeMax":     timeMax,
+		"timeStdDev":  timeStdDev,
+		"packets":     packets,
+		"p50":         percentiles["p50"],
+		"p90":         percentiles["p90"],
+		"p95":         percentiles["p95"],
+		"p99":         percentiles["p99"],
+		"jitter":      rttJitter(rtts),
+	}, nil
+}
+
+// pingPacketLineRe pulls the per-probe seq/ttl/time triple out of a system
+// ping reply line. icmp_seq/ttl/time are field labels iputils and its peers
+// leave untranslated even in localized builds, unlike the summary wording.
+var pingPacketLineRe = regexp.MustCompile(`icmp_seq=(\d+).*?ttl=(\d+).*?time[=<]([\d.]+)`)
+
+// parsePingPackets extracts a per-probe packets slice (matching the shape
+// the native pinger produces) from system ping's textual output.
+func parsePingPackets(output string) ([]map[string]interface{}, []float64) {
+	matches := pingPacketLineRe.FindAllStringSubmatch(output, -1)
+	packets := make([]map[string]interface{}, 0, len(matches))
+	rtts := make([]float64, 0, len(matches))
+
+	for _, m := range matches {
+		seq, _ := strconv.Atoi(m[1])
+		ttl, _ := strconv.Atoi(m[2])
+		rtt, _ := strconv.ParseFloat(m[3], 64)
+		rtts = append(rtts, rtt)
+		packets = append(packets, map[string]interface{}{
+			"seq": seq, "rtt_ms": rtt, "ttl": ttl, "received": true,
+		})
+	}
+
+	return packets, rtts
+}
+
+// rttStats computes min/avg/max/stddev over a slice of round-trip times.
+func rttStats(rtts []float64) (min, avg, max, stdDev float64) {
+	if len(rtts) == 0 {
+		return 0, 0, 0, 0
+	}
+
+	min, max = rtts[0], rtts[0]
+	sum := 0.0
+	for _, rtt := range rtts {
+		if rtt < min {
+			min = rtt
+		}
+		if rtt > max {
+			max = rtt
+		}
+		sum += rtt
+	}
+	avg = sum / float64(len(rtts))
+
+	variance := 0.0
+	for _, rtt := range rtts {
+		variance += (rtt - avg) * (rtt - avg)
+	}
+	variance /= float64(len(rtts))
+	stdDev = math.Sqrt(variance)
+
+	return min, avg, max, stdDev
+}
+
+// rttPercentiles computes p50/p90/p95/p99 over the received RTTs by sorting
+// them and linearly interpolating between the two nearest ranks.
+func rttPercentiles(rtts []float64) map[string]float64 {
+	percentiles := map[string]float64{"p50": 0, "p90": 0, "p95": 0, "p99": 0}
+	if len(rtts) == 0 {
+		return percentiles
+	}
+
+	sorted := append([]float64(nil), rtts...)
+	sort.Float64s(sorted)
+
+	for label, p := range map[string]float64{"p50": 50, "p90": 90, "p95": 95, "p99": 99} {
+		percentiles[label] = percentileRank(sorted, p)
+	}
+	return percentiles
+}
+
+// percentileRank returns the p-th percentile (0-100) of an already-sorted
+// slice, interpolating between the two nearest ranks.
+func percentileRank(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	if lo == hi {
+		return sorted[lo]
+	}
+	frac := rank - float64(lo)
+	return sorted[lo] + frac*(sorted[hi]-sorted[lo])
+}
+
+// rttJitter is the mean absolute successive difference between consecutive
+// received RTTs, in arrival order.
+func rttJitter(rtts []float64) float64 {
+	if len(rtts) < 2 {
+		return 0
+	}
+	sum := 0.0
+	for i := 1; i < len(rtts); i++ {
+		sum += math.Abs(rtts[i] - rtts[i-1])
+	}
+	return sum / float64(len(rtts)-1)
 }
 
 // simulatedPing provides simulated ping results when real ping isn't available
@@ -145,12 +904,22 @@ func (p *PingPlugin) simulatedPing(host string, count int) map[string]interface{
 	timeStdDev := 0.789
 
 	// Generate a realistic looking output
+	rtts := make([]float64, 0, count)
+	packets := make([]map[string]interface{}, 0, count)
 	fmt.Fprintf(&rawOutput, "PING %s (%s) 56(84) bytes of data.\n", host, resolvedIP)
 	for i := 1; i <= count; i++ {
 		if i < count { // Make the last packet "lost" for our simulation
 			pingTime := timeMin + float64(i)/float64(count)*(timeMax-timeMin)
 			fmt.Fprintf(&rawOutput, "64 bytes from %s: icmp_seq=%d ttl=64 time=%.1f ms\n",
 				resolvedIP, i, pingTime)
+			rtts = append(rtts, pingTime)
+			packets = append(packets, map[string]interface{}{
+				"seq": i, "rtt_ms": pingTime, "ttl": 64, "received": true,
+			})
+		} else {
+			packets = append(packets, map[string]interface{}{
+				"seq": i, "rtt_ms": 0.0, "ttl": 0, "received": false,
+			})
 		}
 	}
 
@@ -161,8 +930,16 @@ func (p *PingPlugin) simulatedPing(host string, count int) map[string]interface{
 	fmt.Fprintf(&rawOutput, "rtt min/avg/max/mdev = %.3f/%.3f/%.3f/%.3f ms\n",
 		timeMin, timeAvg, timeMax, timeStdDev)
 
+	percentiles := rttPercentiles(rtts)
+
 	return map[string]interface{}{
 		"host":        host,
+		"packets":     packets,
+		"p50":         percentiles["p50"],
+		"p90":         percentiles["p90"],
+		"p95":         percentiles["p95"],
+		"p99":         percentiles["p99"],
+		"jitter":      rttJitter(rtts),
 		"transmitted": transmitted,
 		"received":    received,
 		"packetLoss":  packetLoss,
@@ -177,6 +954,207 @@ func (p *PingPlugin) simulatedPing(host string, count int) map[string]interface{
 	}
 }
 
+// serveConfig describes the hosts and probe parameters loaded from the
+// --config=path.json file for --serve mode.
+type serveConfig struct {
+	Hosts      []string `json:"hosts"`
+	Interval   float64  `json:"interval"` // seconds between probes per host, default 5
+	Count      int      `json:"count"`    // probes per tick, default 4
+	Method     string   `json:"method"`
+	IPVersion  string   `json:"ipVersion"`
+	TTL        int      `json:"ttl"`
+	Timeout    float64  `json:"timeout"`
+	PacketSize int      `json:"packetSize"`
+}
+
+// params converts the serve config's probe options into the generic params
+// map performPing/pingHost expect.
+func (c serveConfig) params() map[string]interface{} {
+	params := map[string]interface{}{}
+	if c.Method != "" {
+		params["method"] = c.Method
+	}
+	if c.IPVersion != "" {
+		params["ipVersion"] = c.IPVersion
+	}
+	if c.TTL > 0 {
+		params["ttl"] = float64(c.TTL)
+	}
+	if c.Timeout > 0 {
+		params["timeout"] = c.Timeout
+	}
+	if c.PacketSize > 0 {
+		params["packetSize"] = float64(c.PacketSize)
+	}
+	return params
+}
+
+// metricsServer runs one ticker per configured host, reusing PingPlugin's
+// scheduler so the plugin can be scraped by Prometheus in addition to being
+// invoked one-shot via --execute.
+type metricsServer struct {
+	plugin *PingPlugin
+	config serveConfig
+
+	mu      sync.RWMutex
+	results map[string]map[string]interface{}
+	totals  map[string]*hostTotals
+}
+
+// hostTotals accumulates the lifetime transmitted/received counts for a host
+// across ticks, since each tick's result only covers that tick's probes and
+// a Prometheus counter must never go backwards or sit flat between scrapes.
+type hostTotals struct {
+	transmitted int
+	received    int
+}
+
+func newMetricsServer(config serveConfig) *metricsServer {
+	return &metricsServer{
+		plugin:  NewPlugin(),
+		config:  config,
+		results: make(map[string]map[string]interface{}),
+		totals:  make(map[string]*hostTotals),
+	}
+}
+
+// start launches one probe ticker per host; it does not block.
+func (s *metricsServer) start() {
+	interval := time.Duration(s.config.Interval * float64(time.Second))
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	count := s.config.Count
+	if count <= 0 {
+		count = 4
+	}
+	params := s.config.params()
+
+	for _, host := range s.config.Hosts {
+		go s.scheduleHost(host, count, params, interval)
+	}
+}
+
+func (s *metricsServer) scheduleHost(host string, count int, params map[string]interface{}, interval time.Duration) {
+	s.probe(host, count, params)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.probe(host, count, params)
+	}
+}
+
+func (s *metricsServer) probe(host string, count int, params map[string]interface{}) {
+	result := s.plugin.pingHost(host, count, params)
+
+	s.mu.Lock()
+	s.results[host] = result
+	totals := s.totals[host]
+	if totals == nil {
+		totals = &hostTotals{}
+		s.totals[host] = totals
+	}
+	if transmitted, ok := result["transmitted"].(int); ok {
+		totals.transmitted += transmitted
+	}
+	if received, ok := result["received"].(int); ok {
+		totals.received += received
+	}
+	s.mu.Unlock()
+}
+
+// quantileLabels maps the percentile fields performPing computes to the
+// "quantile" label values Prometheus summaries/gauges conventionally use.
+var quantileLabels = map[string]string{
+	"p50": "0.5",
+	"p90": "0.9",
+	"p95": "0.95",
+	"p99": "0.99",
+}
+
+// handleMetrics renders the latest cached result for every configured host
+// as Prometheus/OpenMetrics text exposition format.
+func (s *metricsServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var b strings.Builder
+
+	fmt.Fprintln(&b, "# HELP ping_up Whether the last probe to host received at least one reply.")
+	fmt.Fprintln(&b, "# TYPE ping_up gauge")
+	for host, result := range s.results {
+		up := 0
+		if received, ok := result["received"].(int); ok && received > 0 {
+			up = 1
+		}
+		fmt.Fprintf(&b, "ping_up{host=%q} %d\n", host, up)
+	}
+
+	fmt.Fprintln(&b, "# HELP ping_packet_loss_ratio Fraction of probes to host that received no reply, from 0 to 1.")
+	fmt.Fprintln(&b, "# TYPE ping_packet_loss_ratio gauge")
+	for host, result := range s.results {
+		if loss, ok := result["packetLoss"].(float64); ok {
+			fmt.Fprintf(&b, "ping_packet_loss_ratio{host=%q} %g\n", host, loss/100.0)
+		}
+	}
+
+	fmt.Fprintln(&b, "# HELP ping_packets_transmitted_total Total ICMP echo requests sent to host since this process started.")
+	fmt.Fprintln(&b, "# TYPE ping_packets_transmitted_total counter")
+	for host, totals := range s.totals {
+		fmt.Fprintf(&b, "ping_packets_transmitted_total{host=%q} %d\n", host, totals.transmitted)
+	}
+
+	fmt.Fprintln(&b, "# HELP ping_packets_received_total Total ICMP echo replies received from host since this process started.")
+	fmt.Fprintln(&b, "# TYPE ping_packets_received_total counter")
+	for host, totals := range s.totals {
+		fmt.Fprintf(&b, "ping_packets_received_total{host=%q} %d\n", host, totals.received)
+	}
+
+	fmt.Fprintln(&b, "# HELP ping_rtt_seconds Round-trip time to host, in seconds, at the given quantile.")
+	fmt.Fprintln(&b, "# TYPE ping_rtt_seconds gauge")
+	for host, result := range s.results {
+		for field, quantile := range quantileLabels {
+			if ms, ok := result[field].(float64); ok {
+				fmt.Fprintf(&b, "ping_rtt_seconds{host=%q,quantile=%q} %g\n", host, quantile, ms/1000.0)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, b.String())
+}
+
+// serveMetrics loads the host/probe configuration from configPath, starts
+// the per-host schedulers, and blocks serving /metrics on addr.
+func serveMetrics(addr, configPath string) {
+	configBytes, err := os.ReadFile(configPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var config serveConfig
+	if err := json.Unmarshal(configBytes, &config); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if len(config.Hosts) == 0 {
+		fmt.Println("config must list at least one host")
+		os.Exit(1)
+	}
+
+	server := newMetricsServer(config)
+	server.start()
+
+	http.HandleFunc("/metrics", server.handleMetrics)
+	fmt.Printf("Serving Prometheus metrics for %d host(s) on %s/metrics\n", len(config.Hosts), addr)
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
 // Main function
 func main() {
 	// Create plugin instance
@@ -184,10 +1162,30 @@ func main() {
 
 	// Check command line arguments
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: plugin.go --definition|--execute='{\"params\":...}'")
+		fmt.Println("Usage: plugin.go --definition|--execute='{\"params\":...}'|--serve=:PORT --config=path.json")
 		os.Exit(1)
 	}
 
+	// Handle --serve=:PORT, which starts a Prometheus exposition server
+	// instead of running a single one-shot execution.
+	var serveAddr, configPath string
+	for _, arg := range os.Args[1:] {
+		if strings.HasPrefix(arg, "--serve=") {
+			serveAddr = strings.TrimPrefix(arg, "--serve=")
+		}
+		if strings.HasPrefix(arg, "--config=") {
+			configPath = strings.TrimPrefix(arg, "--config=")
+		}
+	}
+	if serveAddr != "" {
+		if configPath == "" {
+			fmt.Println("--serve requires --config=path.json")
+			os.Exit(1)
+		}
+		serveMetrics(serveAddr, configPath)
+		return
+	}
+
 	// Handle --definition argument
 	if os.Args[1] == "--definition" {
 		// Read plugin.json for definition
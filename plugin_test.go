@@ -0,0 +1,296 @@
+package main
+
+import (
+	"math"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestBuildPingArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		count  int
+		params map[string]interface{}
+		want   []string
+	}{
+		{
+			name:   "defaults",
+			count:  4,
+			params: map[string]interface{}{},
+			want:   []string{"-c", "4", "example.com"},
+		},
+		{
+			name:  "all options set",
+			count: 3,
+			params: map[string]interface{}{
+				"interval":   0.5,
+				"timeout":    2.0,
+				"ttl":        float64(32),
+				"packetSize": float64(128),
+			},
+			want: []string{"-c", "3", "-i", "0.5", "-W", "2", "-t", "32", "-s", "128", "example.com"},
+		},
+		{
+			name:  "zero-valued options are omitted",
+			count: 1,
+			params: map[string]interface{}{
+				"interval":   0.0,
+				"ttl":        float64(0),
+				"packetSize": float64(0),
+			},
+			want: []string{"-c", "1", "example.com"},
+		},
+		{
+			name:   "ipVersion 6 adds -6 regardless of binary choice",
+			count:  4,
+			params: map[string]interface{}{"ipVersion": "6", "binary": "ping"},
+			want:   []string{"-c", "4", "-6", "example.com"},
+		},
+		{
+			name:   "ipVersion 4 adds -4",
+			count:  4,
+			params: map[string]interface{}{"ipVersion": "4"},
+			want:   []string{"-c", "4", "-4", "example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildPingArgs("example.com", tt.count, tt.params)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildPingArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePingOutput(t *testing.T) {
+	tests := []struct {
+		name            string
+		output          string
+		wantTransmitted int
+		wantReceived    int
+		wantLoss        float64
+		wantAvg         float64
+		wantErr         bool
+	}{
+		{
+			name: "english iputils output",
+			output: "PING example.com (93.184.216.34) 56(84) bytes of data.\n" +
+				"64 bytes from 93.184.216.34: icmp_seq=1 ttl=56 time=11.1 ms\n" +
+				"64 bytes from 93.184.216.34: icmp_seq=2 ttl=56 time=11.3 ms\n" +
+				"\n--- example.com ping statistics ---\n" +
+				"2 packets transmitted, 2 received, 0% packet loss, time 1001ms\n" +
+				"rtt min/avg/max/mdev = 11.100/11.200/11.300/0.100 ms\n",
+			wantTransmitted: 2,
+			wantReceived:    2,
+			wantLoss:        0,
+			wantAvg:         11.2,
+		},
+		{
+			name: "french locale output",
+			output: "PING example.com (93.184.216.34) 56(84) octets de donnees.\n" +
+				"64 octets de 93.184.216.34: icmp_seq=1 ttl=56 time=11.1 ms\n" +
+				"\n--- statistiques ping de example.com ---\n" +
+				"4 paquets transmis, 3 recus, 25% de perte\n" +
+				"rtt min/avg/max/mdev = 11.100/11.200/11.300/0.100 ms\n",
+			wantTransmitted: 4,
+			wantReceived:    3,
+			wantLoss:        25,
+			wantAvg:         11.2,
+		},
+		{
+			name:    "unparseable output",
+			output:  "no statistics here",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := parsePingOutput(tt.output)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsePingOutput() expected an error, got stats %+v", stats)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePingOutput() unexpected error: %v", err)
+			}
+			if got := stats["transmitted"]; got != tt.wantTransmitted {
+				t.Errorf("transmitted = %v, want %v", got, tt.wantTransmitted)
+			}
+			if got := stats["received"]; got != tt.wantReceived {
+				t.Errorf("received = %v, want %v", got, tt.wantReceived)
+			}
+			if got := stats["packetLoss"]; got != tt.wantLoss {
+				t.Errorf("packetLoss = %v, want %v", got, tt.wantLoss)
+			}
+			if got := stats["timeAvg"]; got != tt.wantAvg {
+				t.Errorf("timeAvg = %v, want %v", got, tt.wantAvg)
+			}
+		})
+	}
+}
+
+func TestParsePingPackets(t *testing.T) {
+	output := "PING example.com (93.184.216.34) 56(84) bytes of data.\n" +
+		"64 bytes from 93.184.216.34: icmp_seq=1 ttl=56 time=11.1 ms\n" +
+		"64 bytes from 93.184.216.34: icmp_seq=2 ttl=56 time=12.5 ms\n"
+
+	packets, rtts := parsePingPackets(output)
+
+	if len(packets) != 2 {
+		t.Fatalf("len(packets) = %d, want 2", len(packets))
+	}
+	if want := []float64{11.1, 12.5}; !reflect.DeepEqual(rtts, want) {
+		t.Errorf("rtts = %v, want %v", rtts, want)
+	}
+	if packets[0]["seq"] != 1 || packets[0]["ttl"] != 56 || packets[0]["rtt_ms"] != 11.1 || packets[0]["received"] != true {
+		t.Errorf("packets[0] = %+v", packets[0])
+	}
+}
+
+func TestRttPercentiles(t *testing.T) {
+	tests := []struct {
+		name string
+		rtts []float64
+		want map[string]float64
+	}{
+		{
+			name: "no data",
+			rtts: nil,
+			want: map[string]float64{"p50": 0, "p90": 0, "p95": 0, "p99": 0},
+		},
+		{
+			name: "single value",
+			rtts: []float64{10},
+			want: map[string]float64{"p50": 10, "p90": 10, "p95": 10, "p99": 10},
+		},
+		{
+			name: "evenly spaced values interpolate",
+			rtts: []float64{10, 20, 30, 40, 50},
+			want: map[string]float64{"p50": 30, "p90": 46, "p95": 48, "p99": 49.6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rttPercentiles(tt.rtts)
+			for label, want := range tt.want {
+				if math.Abs(got[label]-want) > 1e-9 {
+					t.Errorf("rttPercentiles()[%s] = %v, want %v", label, got[label], want)
+				}
+			}
+		})
+	}
+}
+
+func TestPercentileRank(t *testing.T) {
+	sorted := []float64{10, 20, 30, 40, 50}
+
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 10},
+		{50, 30},
+		{100, 50},
+		{75, 40},
+	}
+
+	for _, tt := range tests {
+		if got := percentileRank(sorted, tt.p); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("percentileRank(%v, %v) = %v, want %v", sorted, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestRttJitter(t *testing.T) {
+	tests := []struct {
+		name string
+		rtts []float64
+		want float64
+	}{
+		{name: "no data", rtts: nil, want: 0},
+		{name: "single value", rtts: []float64{10}, want: 0},
+		{name: "constant rtts have no jitter", rtts: []float64{10, 10, 10}, want: 0},
+		{name: "alternating rtts", rtts: []float64{10, 20, 10, 20}, want: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rttJitter(tt.rtts); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("rttJitter(%v) = %v, want %v", tt.rtts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeerAddrIP(t *testing.T) {
+	ip := net.ParseIP("192.0.2.1")
+
+	tests := []struct {
+		name string
+		addr net.Addr
+		want net.IP
+	}{
+		{name: "ip addr (raw socket)", addr: &net.IPAddr{IP: ip}, want: ip},
+		{name: "udp addr (unprivileged socket)", addr: &net.UDPAddr{IP: ip, Port: 0}, want: ip},
+		{name: "unrecognized addr type", addr: unknownAddr{}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := peerAddrIP(tt.addr); !got.Equal(tt.want) {
+				t.Errorf("peerAddrIP(%v) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+type unknownAddr struct{}
+
+func (unknownAddr) Network() string { return "unknown" }
+func (unknownAddr) String() string  { return "unknown" }
+
+func TestResolvedFamilyFromPingOutput(t *testing.T) {
+	tests := []struct {
+		name         string
+		output       string
+		intendedIPv6 bool
+		want         string
+	}{
+		{
+			name:   "ipv4 banner",
+			output: "PING example.com (93.184.216.34) 56(84) bytes of data.",
+			want:   "4",
+		},
+		{
+			name:   "ipv6 banner",
+			output: "PING example.com (2606:2800:220:1:248:1893:25c8:1946) 56 data bytes",
+			want:   "6",
+		},
+		{
+			name:         "unparseable falls back to intent",
+			output:       "garbage output",
+			intendedIPv6: true,
+			want:         "6",
+		},
+		{
+			name:   "unparseable defaults to v4",
+			output: "garbage output",
+			want:   "4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolvedFamilyFromPingOutput(tt.output, tt.intendedIPv6); got != tt.want {
+				t.Errorf("resolvedFamilyFromPingOutput() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}